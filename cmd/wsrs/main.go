@@ -3,16 +3,22 @@ package main
 import (
 	"context"
 	"errors"
-	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
-	"os"
 	"os/signal"
+	"syscall"
 
 	"github.com/Atharr/rocketseat-go-react-server/internal/api"
+	"github.com/Atharr/rocketseat-go-react-server/internal/api/auth"
+	"github.com/Atharr/rocketseat-go-react-server/internal/api/ratelimit"
+	"github.com/Atharr/rocketseat-go-react-server/internal/config"
 	"github.com/Atharr/rocketseat-go-react-server/internal/store/pgstore"
+	"github.com/gorilla/websocket"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"golang.org/x/time/rate"
 )
 
 func main() {
@@ -20,35 +26,56 @@ func main() {
 		log.Fatal("Error loading .env file: ", err)
 	}
 
-	pool, err := pgxpool.New(context.Background(), fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s",
-		os.Getenv("WSRS_DATABASE_HOST"),
-		os.Getenv("WSRS_DATABASE_PORT"),
-		os.Getenv("WSRS_DATABASE_USER"),
-		os.Getenv("WSRS_DATABASE_PASSWORD"),
-		os.Getenv("WSRS_DATABASE_NAME"),
-	))
+	cfg, err := config.Load()
 	if err != nil {
-		log.Fatal(err)
+		log.Fatal("Error loading config: ", err)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pool, err := pgxpool.New(ctx, cfg.DSN())
+	if err != nil {
+		log.Fatal(err)
+	}
 	defer pool.Close()
 
-	if err := pool.Ping(context.Background()); err != nil {
+	if err := pool.Ping(ctx); err != nil {
 		log.Fatal(err)
 	}
 
-	handler := api.NewHandler(pgstore.New(pool))
+	var authenticator *auth.Authenticator
+	if cfg.AuthSecret != "" {
+		authenticator = auth.New(cfg.AuthSecret)
+	}
+
+	ipLimiter := ratelimit.New(rate.Limit(cfg.RateLimitIPPerSecond), cfg.RateLimitIPBurst, cfg.RateLimitIdleTTL)
+	roomLimiter := ratelimit.New(rate.Limit(cfg.RateLimitRoomPerSecond), cfg.RateLimitRoomBurst, cfg.RateLimitIdleTTL)
+
+	handler := api.NewHandler(pgstore.New(pool), authenticator, ipLimiter, roomLimiter)
+
+	srv := &http.Server{
+		Addr:        cfg.HTTPAddr,
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
 
 	go func() {
-		if err := http.ListenAndServe(":8080", handler); err != nil {
-			if !errors.Is(err, http.ErrServerClosed) {
-				log.Fatal(err)
-			}
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
 		}
 	}()
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
+	<-ctx.Done()
+	stop()
+	slog.Info("shutting down", "timeout", cfg.ShutdownTimeout)
+
+	handler.Close(websocket.CloseNormalClosure, "server is shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Fatal(err)
+	}
 }
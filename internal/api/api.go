@@ -1,13 +1,15 @@
 package api
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
-	"sync"
 
+	"github.com/Atharr/rocketseat-go-react-server/internal/api/auth"
+	"github.com/Atharr/rocketseat-go-react-server/internal/api/ratelimit"
+	"github.com/Atharr/rocketseat-go-react-server/internal/hub"
+	"github.com/Atharr/rocketseat-go-react-server/internal/responder"
 	"github.com/Atharr/rocketseat-go-react-server/internal/store/pgstore"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -15,14 +17,24 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// Handler serves wsrs's HTTP and websocket routes and can be told to
+// close every subscriber connection ahead of a shutdown.
+type Handler interface {
+	http.Handler
+	Close(code int, reason string)
+}
+
 type apiHandler struct {
-	q           *pgstore.Queries
-	r           *chi.Mux
-	upgrader    websocket.Upgrader
-	subscribers map[string]map[*websocket.Conn]context.CancelFunc
-	mu          *sync.Mutex
+	q             *pgstore.Queries
+	r             *chi.Mux
+	upgrader      websocket.Upgrader
+	hub           *hub.Hub
+	authenticator *auth.Authenticator
+	ipLimiter     *ratelimit.Limiter
+	roomLimiter   *ratelimit.Limiter
 }
 
 type Message struct {
@@ -41,23 +53,32 @@ type MessageMessageReactionCount struct {
 	Count int64  `json:"count"`
 }
 
+type MessageMessageAnswered struct {
+	ID string `json:"id"`
+}
+
 const (
-	MsgFailedToGetMessage        = "failed to get message"
-	MsgFailedToGetRoom           = "failed to get room"
-	MsgFailedToGetRoomMessages   = "failed to get room messages"
-	MsgFailedToGetRooms          = "failed to get rooms"
-	MsgFailedToInsertMessage     = "failed to insert message"
-	MsgFailedToInsertRoom        = "failed to insert room"
-	MsgFailedToReactToMessage    = "failed to react to message"
-	MsgFailedToSendMessage       = "failed to send message to client"
-	MsgFailedToUpgradeConnection = "failed to upgrade to websocket connection"
-	MsgInvalidJSON               = "invalid json"
-	MsgInvalidMessageID          = "invalid message id"
-	MsgInvalidRoomID             = "invalid room id"
-	MsgMessageNotFound           = "message not found"
-	MsgNewClientConnected        = "new client connected"
-	MsgRoomNotFound              = "room not found"
-	MsgSomethingWentWrong        = "something went wrong"
+	MsgFailedToGetMessage             = "failed to get message"
+	MsgFailedToGetRoom                = "failed to get room"
+	MsgFailedToGetRoomMessages        = "failed to get room messages"
+	MsgFailedToGetRooms               = "failed to get rooms"
+	MsgFailedToInsertMessage          = "failed to insert message"
+	MsgFailedToInsertRoom             = "failed to insert room"
+	MsgFailedToMarkMessageAsAnswered  = "failed to mark message as answered"
+	MsgFailedToReactToMessage         = "failed to react to message"
+	MsgFailedToRemoveReactFromMessage = "failed to remove react from message"
+	MsgFailedToSendMessage            = "failed to send message to client"
+	MsgFailedToUpgradeConnection      = "failed to upgrade to websocket connection"
+	MsgInvalidJSON                    = "invalid json"
+	MsgInvalidMessageID               = "invalid message id"
+	MsgInvalidRoomID                  = "invalid room id"
+	MsgInvalidVisibility              = "visibility must be \"public\" or \"private\""
+	MsgMessageNotFound                = "message not found"
+	MsgNewClientConnected             = "new client connected"
+	MsgRateLimited                    = "rate limit exceeded"
+	MsgRoomNotFound                   = "room not found"
+	MsgSomethingWentWrong             = "something went wrong"
+	MsgUnauthorized                   = "missing or invalid token"
 )
 
 const (
@@ -67,12 +88,19 @@ const (
 	MessageKindMessageAnswered         = "message_answered"
 )
 
-func NewHandler(q *pgstore.Queries) http.Handler {
+const (
+	RoomVisibilityPublic  = "public"
+	RoomVisibilityPrivate = "private"
+)
+
+func NewHandler(q *pgstore.Queries, authenticator *auth.Authenticator, ipLimiter, roomLimiter *ratelimit.Limiter) Handler {
 	a := apiHandler{
-		q:           q,
-		upgrader:    websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
-		subscribers: make(map[string]map[*websocket.Conn]context.CancelFunc),
-		mu:          &sync.Mutex{},
+		q:             q,
+		upgrader:      websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
+		hub:           hub.New(),
+		authenticator: authenticator,
+		ipLimiter:     ipLimiter,
+		roomLimiter:   roomLimiter,
 	}
 	r := chi.NewRouter()
 
@@ -86,7 +114,7 @@ func NewHandler(q *pgstore.Queries) http.Handler {
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
 
-	r.Get("/subscribe/{room_id}", a.handleSubscribe)
+	r.With(a.requireRoomAuth).Get("/subscribe/{room_id}", a.handleSubscribe)
 
 	r.Route("/api", func(r chi.Router) {
 		r.Route("/rooms", func(r chi.Router) {
@@ -94,17 +122,17 @@ func NewHandler(q *pgstore.Queries) http.Handler {
 			r.Post("/", a.handleCreateRoom)
 
 			r.Route("/{room_id}", func(r chi.Router) {
-				r.Get("/", a.handleGetRoom)
+				r.With(a.requireRoomAuth).Get("/", a.handleGetRoom)
 
 				r.Route("/messages", func(r chi.Router) {
-					r.Get("/", a.handleGetRoomMessages)
-					r.Post("/", a.handleCreateRoomMessage)
+					r.With(a.requireRoomAuth).Get("/", a.handleGetRoomMessages)
+					r.With(a.requireRoomAuth, a.rateLimitByIP, a.rateLimitByRoom).Post("/", a.handleCreateRoomMessage)
 
 					r.Route("/{message_id}", func(r chi.Router) {
-						r.Get("/", a.handleGetRoomMessage)
-						r.Patch("/react", a.handleReactToMessage)
-						r.Delete("/react", a.handleRemoveReactFromMessage)
-						r.Patch("/answer", a.handleMarkMessageAsAnswered)
+						r.With(a.requireRoomAuth).Get("/", a.handleGetRoomMessage)
+						r.With(a.requireRoomAuth, a.rateLimitByIP, a.rateLimitByRoom).Patch("/react", a.handleReactToMessage)
+						r.With(a.requireRoomAuth, a.rateLimitByIP, a.rateLimitByRoom).Delete("/react", a.handleRemoveReactFromMessage)
+						r.With(a.requireRoomAuth, a.rateLimitByIP, a.rateLimitByRoom).Patch("/answer", a.handleMarkMessageAsAnswered)
 					})
 				})
 			})
@@ -119,6 +147,12 @@ func (h apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.r.ServeHTTP(w, r)
 }
 
+// Close sends a close frame with code and reason to every currently
+// subscribed websocket client.
+func (h apiHandler) Close(code int, reason string) {
+	h.hub.CloseAll(code, reason)
+}
+
 func (h apiHandler) handleSubscribe(w http.ResponseWriter, r *http.Request) {
 	_, rawRoomID, _, ok := h.readRoom(w, r)
 	if !ok {
@@ -129,57 +163,39 @@ func (h apiHandler) handleSubscribe(w http.ResponseWriter, r *http.Request) {
 	c, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		slog.Warn(MsgFailedToUpgradeConnection, "error", err)
-		http.Error(w, MsgFailedToUpgradeConnection, http.StatusBadRequest)
+		responder.RespondError(w, http.StatusBadRequest, responder.CodeFailedToUpgradeConnection, MsgFailedToUpgradeConnection)
 		return
 	}
-	defer c.Close()
 
-	// Add the connection to the room
-	ctx, cancel := context.WithCancel(r.Context())
-	h.mu.Lock()
-	if _, ok := h.subscribers[rawRoomID]; !ok {
-		h.subscribers[rawRoomID] = make(map[*websocket.Conn]context.CancelFunc)
-	}
 	slog.Info(MsgNewClientConnected, "room_id", rawRoomID, "client_ip", r.RemoteAddr)
-	h.subscribers[rawRoomID][c] = cancel
-	h.mu.Unlock()
-	<-ctx.Done()
-
-	// Remove the connection from the room
-	h.mu.Lock()
-	delete(h.subscribers[rawRoomID], c)
-	h.mu.Unlock()
+
+	// Serve blocks until the connection is closed, either by the peer or
+	// by the hub dropping it for being too slow.
+	hub.NewClient(h.hub, c, rawRoomID).Serve()
 }
 
 func (h apiHandler) notifyClients(msg Message) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	subcribers, ok := h.subscribers[msg.RoomID]
-	if !ok || len(subcribers) == 0 {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		slog.Error(MsgFailedToSendMessage, "error", err)
 		return
 	}
 
-	for conn, cancel := range subcribers {
-		if err := conn.WriteJSON(msg); err != nil {
-			slog.Error(MsgFailedToSendMessage, "error", err)
-			cancel()
-		}
-	}
+	h.hub.BroadcastRoom(msg.RoomID, payload)
 }
 
 func (h apiHandler) handleGetRooms(w http.ResponseWriter, r *http.Request) {
 	rooms, err := h.q.GetRooms(r.Context())
 	if err != nil {
 		slog.Error(MsgFailedToGetRooms, "error", err)
-		http.Error(w, MsgSomethingWentWrong, http.StatusInternalServerError)
+		responder.RespondError(w, http.StatusInternalServerError, responder.CodeFailedToGetRooms, MsgSomethingWentWrong)
 		return
 	}
 
 	if rooms == nil {
 		rooms = []pgstore.Room{}
 	}
-	sendJSON(w, rooms)
+	responder.RespondJSON(w, http.StatusOK, rooms)
 }
 
 func (h apiHandler) handleGetRoom(w http.ResponseWriter, r *http.Request) {
@@ -188,23 +204,35 @@ func (h apiHandler) handleGetRoom(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sendJSON(w, room)
+	responder.RespondJSON(w, http.StatusOK, room)
 }
 
 func (h apiHandler) handleCreateRoom(w http.ResponseWriter, r *http.Request) {
 	type _body struct {
-		Theme string `json:"theme"`
+		Theme      string `json:"theme"`
+		Visibility string `json:"visibility"`
 	}
 	var body _body
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, MsgInvalidJSON, http.StatusBadRequest)
+		responder.RespondError(w, http.StatusBadRequest, responder.CodeInvalidJSON, MsgInvalidJSON)
 		return
 	}
 
-	roomID, err := h.q.InsertRoom(r.Context(), body.Theme)
+	if body.Visibility == "" {
+		body.Visibility = RoomVisibilityPublic
+	}
+	if body.Visibility != RoomVisibilityPublic && body.Visibility != RoomVisibilityPrivate {
+		responder.RespondError(w, http.StatusBadRequest, responder.CodeInvalidVisibility, MsgInvalidVisibility)
+		return
+	}
+
+	roomID, err := h.q.InsertRoom(r.Context(), pgstore.InsertRoomParams{
+		Theme:      body.Theme,
+		Visibility: body.Visibility,
+	})
 	if err != nil {
 		slog.Error(MsgFailedToInsertRoom, "error", err)
-		http.Error(w, MsgSomethingWentWrong, http.StatusInternalServerError)
+		responder.RespondError(w, http.StatusInternalServerError, responder.CodeFailedToInsertRoom, MsgSomethingWentWrong)
 		return
 	}
 
@@ -212,7 +240,7 @@ func (h apiHandler) handleCreateRoom(w http.ResponseWriter, r *http.Request) {
 		ID string `json:"id"`
 	}
 
-	sendJSON(w, response{ID: roomID.String()})
+	responder.RespondJSON(w, http.StatusOK, response{ID: roomID.String()})
 }
 
 func (h apiHandler) handleGetRoomMessages(w http.ResponseWriter, r *http.Request) {
@@ -224,14 +252,14 @@ func (h apiHandler) handleGetRoomMessages(w http.ResponseWriter, r *http.Request
 	messages, err := h.q.GetRoomMessages(r.Context(), roomID)
 	if err != nil {
 		slog.Error(MsgFailedToGetRoomMessages, "error", err)
-		http.Error(w, MsgSomethingWentWrong, http.StatusInternalServerError)
+		responder.RespondError(w, http.StatusInternalServerError, responder.CodeFailedToGetRoomMessages, MsgSomethingWentWrong)
 		return
 	}
 
 	if messages == nil {
 		messages = []pgstore.Message{}
 	}
-	sendJSON(w, messages)
+	responder.RespondJSON(w, http.StatusOK, messages)
 }
 
 func (h apiHandler) handleCreateRoomMessage(w http.ResponseWriter, r *http.Request) {
@@ -245,17 +273,23 @@ func (h apiHandler) handleCreateRoomMessage(w http.ResponseWriter, r *http.Reque
 	}
 	var body _body
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, MsgInvalidJSON, http.StatusBadRequest)
+		responder.RespondError(w, http.StatusBadRequest, responder.CodeInvalidJSON, MsgInvalidJSON)
 		return
 	}
 
+	var authorID pgtype.Text
+	if identity, ok := auth.IdentityFromContext(r.Context()); ok {
+		authorID = pgtype.Text{String: identity.UserID, Valid: true}
+	}
+
 	messageID, err := h.q.InsertMessage(r.Context(), pgstore.InsertMessageParams{
-		RoomID:  roomID,
-		Message: body.Message,
+		RoomID:   roomID,
+		Message:  body.Message,
+		AuthorID: authorID,
 	})
 	if err != nil {
 		slog.Error(MsgFailedToInsertMessage, "error", err)
-		http.Error(w, MsgSomethingWentWrong, http.StatusInternalServerError)
+		responder.RespondError(w, http.StatusInternalServerError, responder.CodeFailedToInsertMessage, MsgSomethingWentWrong)
 		return
 	}
 
@@ -263,7 +297,7 @@ func (h apiHandler) handleCreateRoomMessage(w http.ResponseWriter, r *http.Reque
 		ID string `json:"id"`
 	}
 
-	sendJSON(w, response{ID: messageID.String()})
+	responder.RespondJSON(w, http.StatusOK, response{ID: messageID.String()})
 
 	go h.notifyClients(Message{
 		Kind:   MessageKindMessageCreated,
@@ -284,22 +318,22 @@ func (h apiHandler) handleGetRoomMessage(w http.ResponseWriter, r *http.Request)
 	rawMessageID := chi.URLParam(r, "message_id")
 	messageID, err := uuid.Parse(rawMessageID)
 	if err != nil {
-		http.Error(w, MsgInvalidMessageID, http.StatusBadRequest)
+		responder.RespondError(w, http.StatusBadRequest, responder.CodeInvalidMessageID, MsgInvalidMessageID)
 		return
 	}
 
 	message, err := h.q.GetMessage(r.Context(), messageID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			http.Error(w, MsgMessageNotFound, http.StatusNotFound)
+			responder.RespondError(w, http.StatusNotFound, responder.CodeMessageNotFound, MsgMessageNotFound)
 			return
 		}
 		slog.Error(MsgFailedToGetMessage, "error", err)
-		http.Error(w, MsgSomethingWentWrong, http.StatusInternalServerError)
+		responder.RespondError(w, http.StatusInternalServerError, responder.CodeFailedToGetMessage, MsgSomethingWentWrong)
 		return
 	}
 
-	sendJSON(w, message)
+	responder.RespondJSON(w, http.StatusOK, message)
 }
 
 func (h apiHandler) handleReactToMessage(w http.ResponseWriter, r *http.Request) {
@@ -311,21 +345,21 @@ func (h apiHandler) handleReactToMessage(w http.ResponseWriter, r *http.Request)
 	rawMessageID := chi.URLParam(r, "message_id")
 	messageID, err := uuid.Parse(rawMessageID)
 	if err != nil {
-		http.Error(w, MsgInvalidMessageID, http.StatusBadRequest)
+		responder.RespondError(w, http.StatusBadRequest, responder.CodeInvalidMessageID, MsgInvalidMessageID)
 		return
 	}
 
 	count, err := h.q.ReactToMessage(r.Context(), messageID)
 	if err != nil {
 		slog.Error(MsgFailedToReactToMessage, "error", err)
-		http.Error(w, MsgSomethingWentWrong, http.StatusInternalServerError)
+		responder.RespondError(w, http.StatusInternalServerError, responder.CodeFailedToReactToMessage, MsgSomethingWentWrong)
 		return
 	}
 
 	type response struct {
 		Count int64 `json:"count"`
 	}
-	sendJSON(w, response{Count: count})
+	responder.RespondJSON(w, http.StatusOK, response{Count: count})
 
 	go h.notifyClients(Message{
 		Kind:   MessageKindMessageRactionIncreased,
@@ -338,9 +372,66 @@ func (h apiHandler) handleReactToMessage(w http.ResponseWriter, r *http.Request)
 }
 
 func (h apiHandler) handleRemoveReactFromMessage(w http.ResponseWriter, r *http.Request) {
+	_, rawRoomID, _, ok := h.readRoom(w, r)
+	if !ok {
+		return
+	}
+
+	rawMessageID := chi.URLParam(r, "message_id")
+	messageID, err := uuid.Parse(rawMessageID)
+	if err != nil {
+		responder.RespondError(w, http.StatusBadRequest, responder.CodeInvalidMessageID, MsgInvalidMessageID)
+		return
+	}
 
+	count, err := h.q.RemoveReactionFromMessage(r.Context(), messageID)
+	if err != nil {
+		slog.Error(MsgFailedToRemoveReactFromMessage, "error", err)
+		responder.RespondError(w, http.StatusInternalServerError, responder.CodeFailedToRemoveReactFromMessage, MsgSomethingWentWrong)
+		return
+	}
+
+	type response struct {
+		Count int64 `json:"count"`
+	}
+	responder.RespondJSON(w, http.StatusOK, response{Count: count})
+
+	go h.notifyClients(Message{
+		Kind:   MessageKindMessageRactionDecreased,
+		RoomID: rawRoomID,
+		Value: MessageMessageReactionCount{
+			ID:    rawMessageID,
+			Count: count,
+		},
+	})
 }
 
 func (h apiHandler) handleMarkMessageAsAnswered(w http.ResponseWriter, r *http.Request) {
+	_, rawRoomID, _, ok := h.readRoom(w, r)
+	if !ok {
+		return
+	}
+
+	rawMessageID := chi.URLParam(r, "message_id")
+	messageID, err := uuid.Parse(rawMessageID)
+	if err != nil {
+		responder.RespondError(w, http.StatusBadRequest, responder.CodeInvalidMessageID, MsgInvalidMessageID)
+		return
+	}
 
+	if err := h.q.MarkMessageAsAnswered(r.Context(), messageID); err != nil {
+		slog.Error(MsgFailedToMarkMessageAsAnswered, "error", err)
+		responder.RespondError(w, http.StatusInternalServerError, responder.CodeFailedToMarkMessageAsAnswered, MsgSomethingWentWrong)
+		return
+	}
+
+	responder.RespondJSON(w, http.StatusOK, struct{}{})
+
+	go h.notifyClients(Message{
+		Kind:   MessageKindMessageAnswered,
+		RoomID: rawRoomID,
+		Value: MessageMessageAnswered{
+			ID: rawMessageID,
+		},
+	})
 }
@@ -0,0 +1,251 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Atharr/rocketseat-go-react-server/internal/api"
+	"github.com/Atharr/rocketseat-go-react-server/internal/store/pgstore"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeDB is a minimal pgstore.DBTX that serves canned responses keyed by
+// which query is being run, so handler tests don't need a real Postgres.
+type fakeDB struct {
+	room          pgstore.Room
+	roomErr       error
+	reactionCount int64
+	reactionErr   error
+	answeredErr   error
+
+	queryRowCalls int
+}
+
+func (f *fakeDB) Exec(_ context.Context, _ string, _ ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, f.answeredErr
+}
+
+func (f *fakeDB) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	f.queryRowCalls++
+	switch {
+	case strings.Contains(sql, "FROM rooms"):
+		return fakeRow{values: []any{f.room.ID, f.room.Theme, f.room.Visibility}, err: f.roomErr}
+	case strings.Contains(sql, "reaction_count"):
+		return fakeRow{values: []any{f.reactionCount}, err: f.reactionErr}
+	default:
+		return fakeRow{err: fmt.Errorf("fakeDB: unexpected QueryRow %q", sql)}
+	}
+}
+
+func (f *fakeDB) Query(_ context.Context, sql string, _ ...any) (pgx.Rows, error) {
+	return nil, fmt.Errorf("fakeDB: unexpected Query %q", sql)
+}
+
+// fakeRow implements pgx.Row over a fixed slice of column values.
+type fakeRow struct {
+	values []any
+	err    error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *uuid.UUID:
+			*d = r.values[i].(uuid.UUID)
+		case *string:
+			*d = r.values[i].(string)
+		case *int64:
+			*d = r.values[i].(int64)
+		case *bool:
+			*d = r.values[i].(bool)
+		default:
+			return fmt.Errorf("fakeRow: unsupported scan destination %T", d)
+		}
+	}
+	return nil
+}
+
+// wsMessage mirrors api.Message's wire shape for decoding broadcasts
+// received over the subscriber websocket.
+type wsMessage struct {
+	Kind  string          `json:"kind"`
+	Value json.RawMessage `json:"value"`
+}
+
+// newTestServer starts an httptest.Server whose handler is backed by db
+// (which serves db.room for every room lookup), with auth and rate
+// limiting disabled.
+func newTestServer(t *testing.T, db *fakeDB) *httptest.Server {
+	t.Helper()
+	handler := api.NewHandler(pgstore.New(db), nil, nil, nil)
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// subscribe dials the room's websocket and then sleeps briefly so the hub
+// has time to register the connection before returning.
+func subscribe(t *testing.T, srv *httptest.Server, roomID uuid.UUID) *websocket.Conn {
+	t.Helper()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/subscribe/" + roomID.String()
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial subscriber websocket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	// The HTTP upgrade response races the hub's registration of the new
+	// client; give the run loop a moment to catch up before a handler
+	// broadcasts to the room.
+	time.Sleep(50 * time.Millisecond)
+	return conn
+}
+
+func readBroadcast(t *testing.T, conn *websocket.Conn) wsMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read broadcast: %v", err)
+	}
+	var msg wsMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("unmarshal broadcast: %v", err)
+	}
+	return msg
+}
+
+func TestHandleMarkMessageAsAnswered(t *testing.T) {
+	roomID := uuid.New()
+	messageID := uuid.New()
+	db := &fakeDB{room: pgstore.Room{ID: roomID, Theme: "theme", Visibility: api.RoomVisibilityPublic}}
+	srv := newTestServer(t, db)
+	conn := subscribe(t, srv, roomID)
+
+	url := fmt.Sprintf("%s/api/rooms/%s/messages/%s/answer", srv.URL, roomID, messageID)
+	req, _ := http.NewRequest(http.MethodPatch, url, nil)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("PATCH /answer: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if strings.TrimSpace(string(body)) != "{}" {
+		t.Fatalf("body = %q, want %q", body, "{}")
+	}
+
+	msg := readBroadcast(t, conn)
+	if msg.Kind != api.MessageKindMessageAnswered {
+		t.Fatalf("kind = %q, want %q", msg.Kind, api.MessageKindMessageAnswered)
+	}
+	var value api.MessageMessageAnswered
+	if err := json.Unmarshal(msg.Value, &value); err != nil {
+		t.Fatalf("unmarshal value: %v", err)
+	}
+	if value.ID != messageID.String() {
+		t.Fatalf("value.ID = %q, want %q", value.ID, messageID.String())
+	}
+}
+
+func TestHandleRemoveReactFromMessage(t *testing.T) {
+	roomID := uuid.New()
+	messageID := uuid.New()
+	db := &fakeDB{
+		room:          pgstore.Room{ID: roomID, Theme: "theme", Visibility: api.RoomVisibilityPublic},
+		reactionCount: 2,
+	}
+	srv := newTestServer(t, db)
+	conn := subscribe(t, srv, roomID)
+
+	url := fmt.Sprintf("%s/api/rooms/%s/messages/%s/react", srv.URL, roomID, messageID)
+	req, _ := http.NewRequest(http.MethodDelete, url, nil)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("DELETE /react: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var respBody struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if respBody.Count != 2 {
+		t.Fatalf("count = %d, want 2", respBody.Count)
+	}
+
+	msg := readBroadcast(t, conn)
+	if msg.Kind != api.MessageKindMessageRactionDecreased {
+		t.Fatalf("kind = %q, want %q", msg.Kind, api.MessageKindMessageRactionDecreased)
+	}
+	var value api.MessageMessageReactionCount
+	if err := json.Unmarshal(msg.Value, &value); err != nil {
+		t.Fatalf("unmarshal value: %v", err)
+	}
+	if value.ID != messageID.String() || value.Count != 2 {
+		t.Fatalf("value = %+v, want {ID: %q, Count: 2}", value, messageID.String())
+	}
+}
+
+// TestHandleRemoveReactFromMessage_FlooredAtZero checks that when
+// RemoveReactionFromMessage reports a count of 0, the handler responds and
+// broadcasts 0. The floor itself is applied by GREATEST in the SQL query,
+// which fakeDB does not execute: it returns the canned count as is.
+func TestHandleRemoveReactFromMessage_FlooredAtZero(t *testing.T) {
+	roomID := uuid.New()
+	messageID := uuid.New()
+	db := &fakeDB{
+		room:          pgstore.Room{ID: roomID, Theme: "theme", Visibility: api.RoomVisibilityPublic},
+		reactionCount: 0,
+	}
+	srv := newTestServer(t, db)
+	conn := subscribe(t, srv, roomID)
+
+	url := fmt.Sprintf("%s/api/rooms/%s/messages/%s/react", srv.URL, roomID, messageID)
+	req, _ := http.NewRequest(http.MethodDelete, url, nil)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("DELETE /react: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var respBody struct {
+		Count int64 `json:"count"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if respBody.Count != 0 {
+		t.Fatalf("count = %d, want 0", respBody.Count)
+	}
+
+	msg := readBroadcast(t, conn)
+	var value api.MessageMessageReactionCount
+	if err := json.Unmarshal(msg.Value, &value); err != nil {
+		t.Fatalf("unmarshal value: %v", err)
+	}
+	if value.Count != 0 {
+		t.Fatalf("broadcast count = %d, want 0", value.Count)
+	}
+}
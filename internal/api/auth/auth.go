@@ -0,0 +1,108 @@
+// Package auth implements bearer-token authentication for wsrs: HMAC-signed
+// JWTs (github.com/golang-jwt/jwt/v5) whose claims identify the caller and,
+// for private-room access, the room they were issued for.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrMissingToken and ErrInvalidToken are returned by Authenticator.Verify
+// and wrapped into 401s by the caller.
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrInvalidToken = errors.New("invalid bearer token")
+)
+
+// Claims are the custom JWT claims wsrs issues and verifies. RoomID scopes
+// the token to a single room; expiry is carried by the standard "exp"
+// claim via jwt.RegisteredClaims.
+type Claims struct {
+	UserID string `json:"sub"`
+	RoomID string `json:"room_id"`
+	jwt.RegisteredClaims
+}
+
+// Identity is the caller attributed to an authenticated request.
+type Identity struct {
+	UserID string
+	RoomID string
+}
+
+// Authenticator issues and verifies bearer tokens signed with a shared
+// HMAC secret.
+type Authenticator struct {
+	secret []byte
+}
+
+// New builds an Authenticator from the given HMAC secret.
+func New(secret string) *Authenticator {
+	return &Authenticator{secret: []byte(secret)}
+}
+
+// Issue signs a token identifying userID, scoped to roomID, expiring
+// after ttl.
+func (a *Authenticator) Issue(userID, roomID string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		UserID: userID,
+		RoomID: roomID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.secret)
+}
+
+// Verify parses and validates raw, rejecting anything not signed with our
+// HMAC secret or past its expires_at.
+func (a *Authenticator) Verify(raw string) (Claims, error) {
+	if raw == "" {
+		return Claims{}, ErrMissingToken
+	}
+
+	var claims Claims
+	token, err := jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+		return a.secret, nil
+	})
+	if err != nil || !token.Valid {
+		return Claims{}, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// TokenFromRequest reads the bearer token from the Authorization header,
+// falling back to a ?token= query parameter since browsers can't set
+// headers on new WebSocket(...).
+func TokenFromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	return r.URL.Query().Get("token")
+}
+
+type identityContextKey struct{}
+
+// WithIdentity returns a copy of ctx carrying identity.
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+// IdentityFromContext returns the identity attached by the auth
+// middleware, if any.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityContextKey{}).(Identity)
+	return identity, ok
+}
@@ -0,0 +1,91 @@
+// Package ratelimit implements keyed token-bucket rate limiting, used to
+// cap writes per remote IP and broadcast fan-out per room so a single
+// client can't flood every subscriber via notifyClients.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// defaultIdleTTL is used when New is given a non-positive idleTTL.
+const defaultIdleTTL = 10 * time.Minute
+
+// Limiter hands out one token bucket per key, evicting buckets that have
+// gone idle so memory stays bounded regardless of how many distinct keys
+// are ever seen.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	r       rate.Limit
+	burst   int
+	idleTTL time.Duration
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// New builds a Limiter whose per-key buckets allow r events per second
+// with the given burst, and starts a sweeper goroutine that evicts
+// buckets unused for idleTTL. idleTTL must be positive; New falls back
+// to defaultIdleTTL otherwise rather than handing time.NewTicker a
+// non-positive interval, which panics.
+func New(r rate.Limit, burst int, idleTTL time.Duration) *Limiter {
+	if idleTTL <= 0 {
+		idleTTL = defaultIdleTTL
+	}
+
+	l := &Limiter{
+		buckets: make(map[string]*bucket),
+		r:       r,
+		burst:   burst,
+		idleTTL: idleTTL,
+	}
+	go l.sweep()
+	return l
+}
+
+// Allow reports whether an event keyed by key is allowed right now. When
+// it isn't, retryAfter is how long the caller should wait before trying
+// again.
+func (l *Limiter) Allow(key string) (allowed bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.r, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = time.Now()
+	reservation := b.limiter.Reserve()
+	l.mu.Unlock()
+
+	if !reservation.OK() {
+		return false, 0
+	}
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return false, delay
+	}
+	return true, 0
+}
+
+// sweep periodically drops buckets that haven't been touched in idleTTL.
+func (l *Limiter) sweep() {
+	ticker := time.NewTicker(l.idleTTL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-l.idleTTL)
+		l.mu.Lock()
+		for key, b := range l.buckets {
+			if b.lastSeen.Before(cutoff) {
+				delete(l.buckets, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
@@ -1,43 +1,162 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
 
+	"github.com/Atharr/rocketseat-go-react-server/internal/api/auth"
+	"github.com/Atharr/rocketseat-go-react-server/internal/api/ratelimit"
+	"github.com/Atharr/rocketseat-go-react-server/internal/responder"
 	"github.com/Atharr/rocketseat-go-react-server/internal/store/pgstore"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
+// roomContextKey is the context key requireRoomAuth stashes an
+// already-fetched room under, so handlers reuse it via readRoom instead
+// of issuing a second GetRoom query for the same request.
+type roomContextKey struct{}
+
+type contextRoom struct {
+	room   pgstore.Room
+	rawID  string
+	roomID uuid.UUID
+}
+
+func withRoom(ctx context.Context, cr contextRoom) context.Context {
+	return context.WithValue(ctx, roomContextKey{}, cr)
+}
+
 func (h apiHandler) readRoom(w http.ResponseWriter, r *http.Request) (room pgstore.Room,
 	rawRoomID string, roomID uuid.UUID, ok bool) {
+	if cr, cached := r.Context().Value(roomContextKey{}).(contextRoom); cached {
+		return cr.room, cr.rawID, cr.roomID, true
+	}
+
 	rawRoomID = chi.URLParam(r, "room_id")
 	roomID, err := uuid.Parse(rawRoomID)
 	if err != nil {
-		http.Error(w, MsgInvalidRoomID, http.StatusBadRequest)
+		responder.RespondError(w, http.StatusBadRequest, responder.CodeInvalidRoomID, MsgInvalidRoomID)
 		return pgstore.Room{}, "", uuid.UUID{}, false
 	}
 
 	room, err = h.q.GetRoom(r.Context(), roomID)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			http.Error(w, MsgRoomNotFound, http.StatusBadRequest)
+			responder.RespondError(w, http.StatusBadRequest, responder.CodeRoomNotFound, MsgRoomNotFound)
 			return pgstore.Room{}, "", uuid.UUID{}, false
 		}
 
 		slog.Error(MsgFailedToGetRoom, "error", err)
-		http.Error(w, MsgSomethingWentWrong, http.StatusInternalServerError)
+		responder.RespondError(w, http.StatusInternalServerError, responder.CodeFailedToGetRoom, MsgSomethingWentWrong)
 		return pgstore.Room{}, "", uuid.UUID{}, false
 	}
 
 	return room, rawRoomID, roomID, true
 }
 
-func sendJSON(w http.ResponseWriter, rawData any) {
-	data, _ := json.Marshal(rawData)
-	w.Header().Set("Content-Type", "application/json")
-	_, _ = w.Write(data)
+// requireRoomAuth gates access to a private room behind a bearer token
+// scoped to that room, on both reads and writes. Public rooms pass
+// through unchecked. Private rooms fail closed: with no authenticator
+// configured nobody can ever hold a valid token, so private rooms become
+// completely unreachable rather than silently public. On success the
+// room it already fetched is attached to the request context so the
+// handler's own readRoom call reuses it instead of re-querying, and, for
+// private rooms, the parsed identity is attached via auth.WithIdentity.
+func (h apiHandler) requireRoomAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		room, rawRoomID, roomID, ok := h.readRoom(w, r)
+		if !ok {
+			return
+		}
+		ctx := withRoom(r.Context(), contextRoom{room: room, rawID: rawRoomID, roomID: roomID})
+
+		if room.Visibility != RoomVisibilityPrivate {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		if h.authenticator == nil {
+			responder.RespondError(w, http.StatusUnauthorized, responder.CodeUnauthorized, MsgUnauthorized)
+			return
+		}
+
+		claims, err := h.authenticator.Verify(auth.TokenFromRequest(r))
+		if err != nil || claims.RoomID != rawRoomID {
+			responder.RespondError(w, http.StatusUnauthorized, responder.CodeUnauthorized, MsgUnauthorized)
+			return
+		}
+
+		ctx = auth.WithIdentity(ctx, auth.Identity{UserID: claims.UserID, RoomID: claims.RoomID})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// rateLimitByIP rejects requests once the caller's remote IP has
+// exhausted its write budget, returning 429 with a Retry-After header.
+// A nil limiter (rate limiting disabled) passes every request through.
+func (h apiHandler) rateLimitByIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.ipLimiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !h.allow(w, h.ipLimiter, middleware.GetReqID(r.Context()), clientIP(r)) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the request's remote address with its ephemeral port
+// stripped, so repeated connections from the same client share a bucket.
+// wsrs isn't deployed behind a trusted reverse proxy, so this
+// deliberately ignores X-Forwarded-For/X-Real-IP: trusting them here
+// would let any client spoof its way around the limiter.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimitByRoom rejects requests once the target room's broadcast
+// fan-out budget is exhausted, returning 429 with a Retry-After header.
+// A nil limiter (rate limiting disabled) passes every request through.
+func (h apiHandler) rateLimitByRoom(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.roomLimiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rawRoomID := chi.URLParam(r, "room_id")
+		if !h.allow(w, h.roomLimiter, middleware.GetReqID(r.Context()), rawRoomID) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow checks key against limiter, writing a 429 structured error with
+// Retry-After and returning false if the request should be rejected.
+// reqID is logged alongside the rejection for correlation.
+func (h apiHandler) allow(w http.ResponseWriter, limiter *ratelimit.Limiter, reqID, key string) bool {
+	ok, retryAfter := limiter.Allow(key)
+	if ok {
+		return true
+	}
+
+	slog.Warn(MsgRateLimited, "request_id", reqID, "key", key)
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+1)))
+	responder.RespondError(w, http.StatusTooManyRequests, responder.CodeRateLimited, MsgRateLimited)
+	return false
 }
@@ -0,0 +1,143 @@
+// Package config loads and validates wsrs's environment-based
+// configuration, so invalid deployments fail fast at startup instead of
+// on the first database call.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultHTTPAddr        = ":8080"
+	defaultShutdownTimeout = 10 * time.Second
+
+	defaultRateLimitIPPerSecond   = 5
+	defaultRateLimitIPBurst       = 10
+	defaultRateLimitRoomPerSecond = 20
+	defaultRateLimitRoomBurst     = 40
+	defaultRateLimitIdleTTL       = 10 * time.Minute
+)
+
+// Config holds everything main needs to bring the server up.
+type Config struct {
+	HTTPAddr        string
+	ShutdownTimeout time.Duration
+
+	DatabaseHost     string
+	DatabasePort     string
+	DatabaseUser     string
+	DatabasePassword string
+	DatabaseName     string
+
+	// AuthSecret is the HMAC secret used to sign and verify room auth
+	// tokens. Empty disables auth: private rooms become unreachable
+	// behind a token nobody can issue, but public rooms and existing
+	// routes keep working.
+	AuthSecret string
+
+	RateLimitIPPerSecond   float64
+	RateLimitIPBurst       int
+	RateLimitRoomPerSecond float64
+	RateLimitRoomBurst     int
+	RateLimitIdleTTL       time.Duration
+}
+
+// Load reads the WSRS_* environment variables, applying defaults where
+// documented, and fails if anything required is missing or malformed.
+func Load() (Config, error) {
+	cfg := Config{
+		HTTPAddr:        envOr("WSRS_HTTP_ADDR", defaultHTTPAddr),
+		ShutdownTimeout: defaultShutdownTimeout,
+
+		DatabaseHost:     os.Getenv("WSRS_DATABASE_HOST"),
+		DatabasePort:     os.Getenv("WSRS_DATABASE_PORT"),
+		DatabaseUser:     os.Getenv("WSRS_DATABASE_USER"),
+		DatabasePassword: os.Getenv("WSRS_DATABASE_PASSWORD"),
+		DatabaseName:     os.Getenv("WSRS_DATABASE_NAME"),
+
+		AuthSecret: os.Getenv("WSRS_AUTH_SECRET"),
+
+		RateLimitIPPerSecond:   defaultRateLimitIPPerSecond,
+		RateLimitIPBurst:       defaultRateLimitIPBurst,
+		RateLimitRoomPerSecond: defaultRateLimitRoomPerSecond,
+		RateLimitRoomBurst:     defaultRateLimitRoomBurst,
+		RateLimitIdleTTL:       defaultRateLimitIdleTTL,
+	}
+
+	if raw := os.Getenv("WSRS_SHUTDOWN_TIMEOUT"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid WSRS_SHUTDOWN_TIMEOUT: %w", err)
+		}
+		cfg.ShutdownTimeout = d
+	}
+
+	for env, dst := range map[string]*float64{
+		"WSRS_RATE_LIMIT_IP_PER_SECOND":   &cfg.RateLimitIPPerSecond,
+		"WSRS_RATE_LIMIT_ROOM_PER_SECOND": &cfg.RateLimitRoomPerSecond,
+	} {
+		if raw := os.Getenv(env); raw != "" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid %s: %w", env, err)
+			}
+			*dst = v
+		}
+	}
+
+	for env, dst := range map[string]*int{
+		"WSRS_RATE_LIMIT_IP_BURST":   &cfg.RateLimitIPBurst,
+		"WSRS_RATE_LIMIT_ROOM_BURST": &cfg.RateLimitRoomBurst,
+	} {
+		if raw := os.Getenv(env); raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid %s: %w", env, err)
+			}
+			*dst = v
+		}
+	}
+
+	if raw := os.Getenv("WSRS_RATE_LIMIT_IDLE_TTL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid WSRS_RATE_LIMIT_IDLE_TTL: %w", err)
+		}
+		cfg.RateLimitIdleTTL = d
+	}
+
+	var missing []string
+	for name, v := range map[string]string{
+		"WSRS_DATABASE_HOST": cfg.DatabaseHost,
+		"WSRS_DATABASE_PORT": cfg.DatabasePort,
+		"WSRS_DATABASE_USER": cfg.DatabaseUser,
+		"WSRS_DATABASE_NAME": cfg.DatabaseName,
+	} {
+		if v == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return Config{}, fmt.Errorf("missing required environment variables: %v", missing)
+	}
+
+	return cfg, nil
+}
+
+// DSN builds the libpq connection string pgxpool expects.
+func (c Config) DSN() string {
+	return fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s",
+		c.DatabaseHost, c.DatabasePort, c.DatabaseUser, c.DatabasePassword, c.DatabaseName,
+	)
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
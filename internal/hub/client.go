@@ -0,0 +1,125 @@
+package hub
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait is the time allowed to write a message to the peer.
+	writeWait = 10 * time.Second
+
+	// pongWait is the time allowed to read the next pong message from
+	// the peer.
+	pongWait = 60 * time.Second
+
+	// pingPeriod sends pings to the peer with this period; must be less
+	// than pongWait.
+	pingPeriod = (pongWait * 9) / 10
+
+	// maxMessageSize is the maximum message size allowed from the peer.
+	maxMessageSize = 4096
+
+	// sendBufferSize is how many pending broadcasts a client tolerates
+	// before it's considered too slow and dropped from the room.
+	sendBufferSize = 16
+)
+
+// Client is a single websocket connection subscribed to one room. It owns
+// a buffered send queue so a slow client never blocks the hub's run loop.
+type Client struct {
+	hub    *Hub
+	conn   *websocket.Conn
+	roomID string
+	send   chan []byte
+}
+
+// NewClient wraps conn as a Client of the given room. Call Serve to start
+// reading and writing.
+func NewClient(h *Hub, conn *websocket.Conn, roomID string) *Client {
+	return &Client{
+		hub:    h,
+		conn:   conn,
+		roomID: roomID,
+		send:   make(chan []byte, sendBufferSize),
+	}
+}
+
+// Serve registers the client with its hub and blocks until the connection
+// is closed, either by the peer, by the hub dropping a slow client, or by
+// the hub shutting down.
+func (c *Client) Serve() {
+	c.hub.Register(c)
+
+	done := make(chan struct{})
+	go func() {
+		c.writePump()
+		close(done)
+	}()
+
+	c.readPump()
+	<-done
+}
+
+// close sends a close frame directly to the peer, bypassing the send
+// queue so it can't be starved by pending broadcasts. WriteControl is
+// safe to call concurrently with writePump's use of WriteMessage.
+func (c *Client) close(code int, reason string) {
+	deadline := time.Now().Add(writeWait)
+	_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+}
+
+// readPump pumps incoming frames off the connection so control frames
+// (pong, close) are processed; wsrs doesn't expect any client->server
+// payloads over this socket, so data frames are discarded.
+func (c *Client) readPump() {
+	defer c.hub.Unregister(c)
+
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				slog.Warn("websocket read error", "error", err, "room_id", c.roomID)
+			}
+			return
+		}
+	}
+}
+
+// writePump drains the send queue to the connection and emits periodic
+// pings; it is the only goroutine that writes data and ping frames to
+// conn (close frames from the hub go through close via WriteControl).
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload, ok := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package hub implements a per-process websocket hub modeled on the
+// classic hub/client pattern: a single goroutine owns room membership and
+// fans broadcasts out to per-connection send queues, so one slow reader
+// can never block delivery to the rest of the room.
+package hub
+
+// Hub maintains the set of rooms and their subscribed clients, and fans
+// broadcasts out to them. The zero value is not usable; use New.
+type Hub struct {
+	rooms map[string]map[*Client]struct{}
+
+	register   chan *Client
+	unregister chan *Client
+	broadcast  chan roomMessage
+	closeAll   chan closeAllRequest
+}
+
+type roomMessage struct {
+	roomID  string
+	payload []byte
+}
+
+type closeAllRequest struct {
+	code   int
+	reason string
+	done   chan struct{}
+}
+
+// New creates a Hub and starts its run loop.
+func New() *Hub {
+	h := &Hub{
+		rooms:      make(map[string]map[*Client]struct{}),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		broadcast:  make(chan roomMessage),
+		closeAll:   make(chan closeAllRequest),
+	}
+	go h.run()
+	return h
+}
+
+func (h *Hub) run() {
+	for {
+		select {
+		case c := <-h.register:
+			clients, ok := h.rooms[c.roomID]
+			if !ok {
+				clients = make(map[*Client]struct{})
+				h.rooms[c.roomID] = clients
+			}
+			clients[c] = struct{}{}
+
+		case c := <-h.unregister:
+			h.removeClient(c)
+
+		case m := <-h.broadcast:
+			for c := range h.rooms[m.roomID] {
+				select {
+				case c.send <- m.payload:
+				default:
+					// c isn't keeping up; drop it instead of blocking
+					// broadcasts to the rest of the room.
+					h.removeClient(c)
+				}
+			}
+
+		case req := <-h.closeAll:
+			for _, clients := range h.rooms {
+				for c := range clients {
+					c.close(req.code, req.reason)
+				}
+			}
+			close(req.done)
+		}
+	}
+}
+
+func (h *Hub) removeClient(c *Client) {
+	clients, ok := h.rooms[c.roomID]
+	if !ok {
+		return
+	}
+	if _, ok := clients[c]; !ok {
+		return
+	}
+
+	delete(clients, c)
+	if len(clients) == 0 {
+		delete(h.rooms, c.roomID)
+	}
+	close(c.send)
+}
+
+// Register subscribes c to its room. It blocks until the hub's run loop
+// picks it up, so it is safe to call concurrently with broadcasts.
+func (h *Hub) Register(c *Client) {
+	h.register <- c
+}
+
+// Unregister removes c from its room, closing its send channel so its
+// writer goroutine can exit.
+func (h *Hub) Unregister(c *Client) {
+	h.unregister <- c
+}
+
+// BroadcastRoom enqueues payload for delivery to every client currently
+// subscribed to roomID. Safe to call from any goroutine.
+func (h *Hub) BroadcastRoom(roomID string, payload []byte) {
+	h.broadcast <- roomMessage{roomID: roomID, payload: payload}
+}
+
+// CloseAll sends a close frame with code and reason to every subscriber
+// across every room, so clients can distinguish a planned shutdown from a
+// network error. It blocks until every client has been notified.
+func (h *Hub) CloseAll(code int, reason string) {
+	done := make(chan struct{})
+	h.closeAll <- closeAllRequest{code: code, reason: reason, done: done}
+	<-done
+}
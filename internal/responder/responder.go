@@ -0,0 +1,70 @@
+// Package responder writes JSON HTTP responses with a consistent shape,
+// including structured errors carrying a stable, machine-readable code
+// distinct from the human-readable message (the Dendrite spec.MatrixError
+// pattern): clients should match on ErrCode, never on Error.
+package responder
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// ErrorCode is a stable identifier for a class of error response. It never
+// changes across releases, unlike the human-readable message alongside it.
+type ErrorCode string
+
+const (
+	CodeFailedToGetMessage             ErrorCode = "WSRS_FAILED_TO_GET_MESSAGE"
+	CodeFailedToGetRoom                ErrorCode = "WSRS_FAILED_TO_GET_ROOM"
+	CodeFailedToGetRoomMessages        ErrorCode = "WSRS_FAILED_TO_GET_ROOM_MESSAGES"
+	CodeFailedToGetRooms               ErrorCode = "WSRS_FAILED_TO_GET_ROOMS"
+	CodeFailedToInsertMessage          ErrorCode = "WSRS_FAILED_TO_INSERT_MESSAGE"
+	CodeFailedToInsertRoom             ErrorCode = "WSRS_FAILED_TO_INSERT_ROOM"
+	CodeFailedToMarkMessageAsAnswered  ErrorCode = "WSRS_FAILED_TO_MARK_MESSAGE_AS_ANSWERED"
+	CodeFailedToReactToMessage         ErrorCode = "WSRS_FAILED_TO_REACT_TO_MESSAGE"
+	CodeFailedToRemoveReactFromMessage ErrorCode = "WSRS_FAILED_TO_REMOVE_REACT_FROM_MESSAGE"
+	CodeFailedToUpgradeConnection      ErrorCode = "WSRS_FAILED_TO_UPGRADE_CONNECTION"
+	CodeInvalidJSON                    ErrorCode = "WSRS_INVALID_JSON"
+	CodeInvalidMessageID               ErrorCode = "WSRS_INVALID_MESSAGE_ID"
+	CodeInvalidRoomID                  ErrorCode = "WSRS_INVALID_ROOM_ID"
+	CodeInvalidVisibility              ErrorCode = "WSRS_INVALID_VISIBILITY"
+	CodeMessageNotFound                ErrorCode = "WSRS_MESSAGE_NOT_FOUND"
+	CodeRateLimited                    ErrorCode = "WSRS_RATE_LIMITED"
+	CodeRoomNotFound                   ErrorCode = "WSRS_ROOM_NOT_FOUND"
+	CodeSomethingWentWrong             ErrorCode = "WSRS_SOMETHING_WENT_WRONG"
+	CodeUnauthorized                   ErrorCode = "WSRS_UNAUTHORIZED"
+)
+
+// errorBody is the wire format written by RespondError.
+type errorBody struct {
+	ErrCode ErrorCode `json:"errcode"`
+	Error   string    `json:"error"`
+	Status  int       `json:"status"`
+}
+
+// RespondJSON writes body as a JSON response with the given status code,
+// setting Content-Type and calling WriteHeader before the body is
+// written.
+func RespondJSON(w http.ResponseWriter, status int, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		slog.Error("failed to marshal response body", "error", err)
+		RespondError(w, http.StatusInternalServerError, CodeSomethingWentWrong, "something went wrong")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// RespondError writes a structured JSON error response with a stable,
+// machine-readable code alongside the human-readable msg.
+func RespondError(w http.ResponseWriter, status int, code ErrorCode, msg string) {
+	RespondJSON(w, status, errorBody{
+		ErrCode: code,
+		Error:   msg,
+		Status:  status,
+	})
+}